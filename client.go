@@ -204,6 +204,7 @@ type clientConfig struct {
 	Interceptor            Interceptor
 	CompressionPools       map[string]*compressionPool
 	CompressionNames       []string
+	CompressionLevel       *int
 	Codec                  Codec
 	RequestCompressionName string
 	BufferPool             *bufferPool
@@ -248,6 +249,9 @@ func (c *clientConfig) validate() *Error {
 			return errorf(CodeUnknown, "unknown compression %q", c.RequestCompressionName)
 		}
 	}
+	if c.CompressionLevel != nil && !isValidGzipLevel(*c.CompressionLevel) {
+		return errorf(CodeUnknown, "invalid gzip compression level %d", *c.CompressionLevel)
+	}
 	return nil
 }
 
@@ -37,12 +37,42 @@
 //	gen/path/to/file.pb.go
 //	gen/path/to/connectfoov1/file.connect.go
 //
+// The package_suffix option changes the suffix appended to the Go package
+// name of the generated code. Setting it to an empty string (with protoc,
+// --connect-go_opt=package_suffix) writes the Connect code into the same
+// package as the base Go types:
+//
+//	gen/path/to/file.pb.go
+//	gen/path/to/file.connect.go
+//
+// The generate option limits the output to clients or handlers. It defaults
+// to both; setting it to client omits the handler interfaces and constructors,
+// and setting it to server omits the client interfaces and implementations:
+//
+//	protoc --go_out=gen --connect-go_out=gen --connect-go_opt=generate=client path/to/file.proto
+//
+// Setting the unimplemented_handlers option to false omits the Unimplemented
+// handler structs, for environments where the extra code is unwanted. Handler
+// implementations must then implement every method themselves.
+//
+// The build_constraint option adds a //go:build line to every generated file,
+// for example build_constraint=!wasm. Since protoc separates options with
+// commas, use && and || rather than the legacy comma-separated syntax.
+//
+// The connect_import_path option changes the import path of the Connect
+// runtime used by the generated code, which is useful for organizations that
+// maintain a fork of connectrpc.com/connect. The fork must remain
+// API-compatible with the version of this plugin.
+//
 // [buf]: https://buf.build
 package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
+	"go/build/constraint"
+	"go/token"
 	"os"
 	"path"
 	"path/filepath"
@@ -64,7 +94,16 @@ const (
 	connectPackage = protogen.GoImportPath("connectrpc.com/connect")
 
 	generatedFilenameExtension = ".connect.go"
-	generatedPackageSuffix     = "connect"
+	defaultPackageSuffix       = "connect"
+	packageSuffixFlagName      = "package_suffix"
+	generateFlagName           = "generate"
+	connectImportPathFlagName  = "connect_import_path"
+	unimplementedFlagName      = "unimplemented_handlers"
+	buildConstraintFlagName    = "build_constraint"
+
+	generateBoth   = "both"
+	generateClient = "client"
+	generateServer = "server"
 
 	usage = "See https://connectrpc.com/docs/go/getting-started to learn how to use this plugin.\n\nFlags:\n  -h, --help\tPrint this help and exit.\n      --version\tPrint the version and exit."
 
@@ -89,50 +128,132 @@ func main() {
 		fmt.Fprintln(os.Stderr, usage)
 		os.Exit(1)
 	}
-	protogen.Options{}.Run(
-		func(plugin *protogen.Plugin) error {
-			plugin.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL) | uint64(pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)
-			plugin.SupportedEditionsMinimum = descriptorpb.Edition_EDITION_PROTO2
-			plugin.SupportedEditionsMaximum = descriptorpb.Edition_EDITION_2023
-			for _, file := range plugin.Files {
-				if file.Generate {
-					generate(plugin, file)
-				}
+	options, run := newPluginOptions()
+	options.Run(run)
+}
+
+// newPluginOptions registers the plugin's parameters and returns protogen
+// options that parse them, along with the function that generates code.
+func newPluginOptions() (protogen.Options, func(*protogen.Plugin) error) {
+	var flagSet flag.FlagSet
+	packageSuffix := flagSet.String(
+		packageSuffixFlagName,
+		defaultPackageSuffix,
+		"Generate files into a sub-package of the package containing the base .pb.go files using the given suffix. An empty suffix denotes to generate into the same package as the base pb.go files.",
+	)
+	generateMode := flagSet.String(
+		generateFlagName,
+		generateBoth,
+		"Generate clients, servers, or both. Must be one of client, server, or both.",
+	)
+	unimplemented := flagSet.Bool(
+		unimplementedFlagName,
+		true,
+		"Generate Unimplemented handler structs that return CodeUnimplemented from all methods.",
+	)
+	buildConstraint := flagSet.String(
+		buildConstraintFlagName,
+		"",
+		"Add the given //go:build constraint to every generated file.",
+	)
+	connectImportPath := flagSet.String(
+		connectImportPathFlagName,
+		string(connectPackage),
+		"Import the Connect runtime from the given path instead of connectrpc.com/connect.",
+	)
+	options := protogen.Options{
+		ParamFunc: flagSet.Set,
+		ImportRewriteFunc: func(importPath protogen.GoImportPath) protogen.GoImportPath {
+			if importPath == connectPackage && *connectImportPath != "" {
+				return protogen.GoImportPath(*connectImportPath)
 			}
-			return nil
+			return importPath
 		},
-	)
+	}
+	return options, func(plugin *protogen.Plugin) error {
+		plugin.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL) | uint64(pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)
+		plugin.SupportedEditionsMinimum = descriptorpb.Edition_EDITION_PROTO2
+		plugin.SupportedEditionsMaximum = descriptorpb.Edition_EDITION_2023
+		if *packageSuffix != "" && !token.IsIdentifier(*packageSuffix) {
+			return fmt.Errorf("%s %q is not a valid Go identifier", packageSuffixFlagName, *packageSuffix)
+		}
+		config := config{
+			packageSuffix:         *packageSuffix,
+			generateUnimplemented: *unimplemented,
+		}
+		switch *generateMode {
+		case generateBoth:
+			config.generateClient, config.generateServer = true, true
+		case generateClient:
+			config.generateClient = true
+		case generateServer:
+			config.generateServer = true
+		default:
+			return fmt.Errorf(
+				"%s %q is invalid: must be one of %s, %s, or %s",
+				generateFlagName, *generateMode, generateClient, generateServer, generateBoth,
+			)
+		}
+		if *buildConstraint != "" {
+			expr, err := constraint.Parse("//go:build " + *buildConstraint)
+			if err != nil {
+				return fmt.Errorf("%s %q is invalid: %w", buildConstraintFlagName, *buildConstraint, err)
+			}
+			config.buildConstraint = expr.String()
+		}
+		for _, file := range plugin.Files {
+			if file.Generate {
+				generate(plugin, file, config)
+			}
+		}
+		return nil
+	}
 }
 
-func generate(plugin *protogen.Plugin, file *protogen.File) {
+// config holds the plugin's parsed parameters.
+type config struct {
+	packageSuffix         string
+	generateClient        bool
+	generateServer        bool
+	generateUnimplemented bool
+	buildConstraint       string
+}
+
+func generate(plugin *protogen.Plugin, file *protogen.File, config config) {
 	if len(file.Services) == 0 {
 		return
 	}
-	file.GoPackageName += generatedPackageSuffix
-
-	generatedFilenamePrefixToSlash := filepath.ToSlash(file.GeneratedFilenamePrefix)
-	file.GeneratedFilenamePrefix = path.Join(
-		path.Dir(generatedFilenamePrefixToSlash),
-		string(file.GoPackageName),
-		path.Base(generatedFilenamePrefixToSlash),
-	)
-	generatedFile := plugin.NewGeneratedFile(
-		file.GeneratedFilenamePrefix+generatedFilenameExtension,
-		protogen.GoImportPath(path.Join(
+	packageSuffix := config.packageSuffix
+	goImportPath := file.GoImportPath
+	if packageSuffix != "" {
+		file.GoPackageName += protogen.GoPackageName(packageSuffix)
+		generatedFilenamePrefixToSlash := filepath.ToSlash(file.GeneratedFilenamePrefix)
+		file.GeneratedFilenamePrefix = path.Join(
+			path.Dir(generatedFilenamePrefixToSlash),
+			string(file.GoPackageName),
+			path.Base(generatedFilenamePrefixToSlash),
+		)
+		goImportPath = protogen.GoImportPath(path.Join(
 			string(file.GoImportPath),
 			string(file.GoPackageName),
-		)),
+		))
+	}
+	generatedFile := plugin.NewGeneratedFile(
+		file.GeneratedFilenamePrefix+generatedFilenameExtension,
+		goImportPath,
 	)
-	generatedFile.Import(file.GoImportPath)
-	generatePreamble(generatedFile, file)
+	if packageSuffix != "" {
+		generatedFile.Import(file.GoImportPath)
+	}
+	generatePreamble(generatedFile, file, config.buildConstraint)
 	generateServiceNameConstants(generatedFile, file.Services)
 	generateServiceNameVariables(generatedFile, file)
 	for _, service := range file.Services {
-		generateService(generatedFile, service)
+		generateService(generatedFile, service, config)
 	}
 }
 
-func generatePreamble(g *protogen.GeneratedFile, file *protogen.File) {
+func generatePreamble(g *protogen.GeneratedFile, file *protogen.File, buildConstraint string) {
 	syntaxPath := protoreflect.SourcePath{protoSyntaxFieldNum}
 	syntaxLocation := file.Desc.SourceLocations().ByPath(syntaxPath)
 	for _, comment := range syntaxLocation.LeadingDetachedComments {
@@ -156,6 +277,10 @@ func generatePreamble(g *protogen.GeneratedFile, file *protogen.File) {
 		g.P("// Source: ", file.Desc.Path())
 	}
 	g.P()
+	if buildConstraint != "" {
+		g.P("//go:build ", buildConstraint)
+		g.P()
+	}
 
 	pkgPath := protoreflect.SourcePath{protoPackageFieldNum}
 	pkgLocation := file.Desc.SourceLocations().ByPath(pkgPath)
@@ -230,13 +355,19 @@ func generateServiceNameVariables(g *protogen.GeneratedFile, file *protogen.File
 	g.P(")")
 }
 
-func generateService(g *protogen.GeneratedFile, service *protogen.Service) {
+func generateService(g *protogen.GeneratedFile, service *protogen.Service, config config) {
 	names := newNames(service)
-	generateClientInterface(g, service, names)
-	generateClientImplementation(g, service, names)
-	generateServerInterface(g, service, names)
-	generateServerConstructor(g, service, names)
-	generateUnimplementedServerImplementation(g, service, names)
+	if config.generateClient {
+		generateClientInterface(g, service, names)
+		generateClientImplementation(g, service, names)
+	}
+	if config.generateServer {
+		generateServerInterface(g, service, names)
+		generateServerConstructor(g, service, names)
+		if config.generateUnimplemented {
+			generateUnimplementedServerImplementation(g, service, names)
+		}
+	}
 }
 
 func generateClientInterface(g *protogen.GeneratedFile, service *protogen.Service, names names) {
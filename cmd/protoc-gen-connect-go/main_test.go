@@ -0,0 +1,206 @@
+// Copyright 2021-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect/internal/assert"
+	collidev1 "connectrpc.com/connect/internal/gen/connect/collide/v1"
+	importv1 "connectrpc.com/connect/internal/gen/connect/import/v1"
+	pingv1 "connectrpc.com/connect/internal/gen/connect/ping/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestGenerateOptions(t *testing.T) {
+	t.Parallel()
+	const defaultName = "connect/ping/v1/pingv1connect/ping.connect.go"
+	tests := []struct {
+		name     string
+		param    string
+		err      string
+		filename string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "default",
+			filename: defaultName,
+			contains: []string{
+				"package pingv1connect",
+				`connect "connectrpc.com/connect"`,
+				"func NewPingServiceClient(",
+				"func NewPingServiceHandler(",
+				"type UnimplementedPingServiceHandler struct",
+			},
+			excludes: []string{"//go:build"},
+		},
+		{
+			name:     "empty_package_suffix",
+			param:    "package_suffix=",
+			filename: "connect/ping/v1/ping.connect.go",
+			contains: []string{"package pingv1\n"},
+		},
+		{
+			name:     "custom_package_suffix",
+			param:    "package_suffix=rpc",
+			filename: "connect/ping/v1/pingv1rpc/ping.connect.go",
+			contains: []string{"package pingv1rpc\n"},
+		},
+		{
+			name:  "invalid_package_suffix",
+			param: "package_suffix=not-valid",
+			err:   `package_suffix "not-valid" is not a valid Go identifier`,
+		},
+		{
+			name:     "generate_client",
+			param:    "generate=client",
+			filename: defaultName,
+			contains: []string{"func NewPingServiceClient("},
+			excludes: []string{"func NewPingServiceHandler(", "UnimplementedPingServiceHandler", `"net/http"`},
+		},
+		{
+			name:     "generate_server",
+			param:    "generate=server",
+			filename: defaultName,
+			contains: []string{"func NewPingServiceHandler(", "type UnimplementedPingServiceHandler struct"},
+			excludes: []string{"func NewPingServiceClient(", "type PingServiceClient interface"},
+		},
+		{
+			name:  "generate_invalid",
+			param: "generate=bogus",
+			err:   `generate "bogus" is invalid`,
+		},
+		{
+			name:     "no_unimplemented_handlers",
+			param:    "unimplemented_handlers=false",
+			filename: defaultName,
+			contains: []string{"func NewPingServiceHandler("},
+			excludes: []string{"UnimplementedPingServiceHandler", `"errors"`},
+		},
+		{
+			name:     "build_constraint",
+			param:    "build_constraint=!wasm && (linux || darwin)",
+			filename: defaultName,
+			contains: []string{"\n//go:build !wasm && (linux || darwin)\n\n"},
+		},
+		{
+			name:  "invalid_build_constraint",
+			param: "build_constraint=!!(",
+			err:   `build_constraint "!!(" is invalid`,
+		},
+		{
+			name:     "connect_import_path",
+			param:    "connect_import_path=example.com/fork/connect",
+			filename: defaultName,
+			contains: []string{`connect "example.com/fork/connect"`},
+			excludes: []string{`"connectrpc.com/connect"`},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			response := testGenerate(t, tt.param)
+			if tt.err != "" {
+				assert.True(t, strings.Contains(response.GetError(), tt.err), assert.Sprintf("error: %q", response.GetError()))
+				assert.Zero(t, len(response.GetFile()))
+				return
+			}
+			assert.Zero(t, response.GetError())
+			assert.Equal(t, len(response.GetFile()), 1)
+			file := response.GetFile()[0]
+			assert.Equal(t, file.GetName(), tt.filename)
+			for _, want := range tt.contains {
+				assert.True(t, strings.Contains(file.GetContent(), want), assert.Sprintf("missing %q", want))
+			}
+			for _, unwanted := range tt.excludes {
+				assert.False(t, strings.Contains(file.GetContent(), unwanted), assert.Sprintf("unexpected %q", unwanted))
+			}
+		})
+	}
+}
+
+func TestGenerateStable(t *testing.T) {
+	t.Parallel()
+	files := []protoreflect.FileDescriptor{
+		pingv1.File_connect_ping_v1_ping_proto,
+		collidev1.File_connect_collide_v1_collide_proto,
+		importv1.File_connect_import_v1_import_proto,
+	}
+	reversed := make([]protoreflect.FileDescriptor, len(files))
+	for i, file := range files {
+		reversed[len(files)-1-i] = file
+	}
+	first := testGenerateFiles(t, "", files...)
+	assert.Zero(t, first.GetError())
+	assert.Equal(t, len(first.GetFile()), len(files))
+	for _, order := range [][]protoreflect.FileDescriptor{files, reversed} {
+		response := testGenerateFiles(t, "", order...)
+		assert.Zero(t, response.GetError())
+		assert.Equal(t, len(response.GetFile()), len(first.GetFile()))
+		content := make(map[string]string)
+		for _, file := range response.GetFile() {
+			content[file.GetName()] = file.GetContent()
+		}
+		for _, want := range first.GetFile() {
+			got, ok := content[want.GetName()]
+			assert.True(t, ok, assert.Sprintf("missing %s", want.GetName()))
+			assert.Equal(t, got, want.GetContent())
+		}
+	}
+}
+
+// testGenerate runs the plugin in-process on ping.proto with the given
+// parameters, in addition to paths=source_relative.
+func testGenerate(t *testing.T, param string) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+	return testGenerateFiles(t, param, pingv1.File_connect_ping_v1_ping_proto)
+}
+
+// testGenerateFiles runs the plugin in-process on the given files, which must
+// not import one another, in the order given.
+func testGenerateFiles(t *testing.T, param string, files ...protoreflect.FileDescriptor) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+	if param != "" {
+		param = "," + param
+	}
+	request := &pluginpb.CodeGeneratorRequest{
+		Parameter: proto.String("paths=source_relative" + param),
+	}
+	for _, descriptor := range files {
+		file := protodesc.ToFileDescriptorProto(descriptor)
+		// Mirror buf's managed mode: connect/ping/v1 becomes package pingv1.
+		dir := path.Dir(file.GetName())
+		file.Options = &descriptorpb.FileOptions{
+			GoPackage: proto.String("connectrpc.com/connect/internal/gen/" + dir + ";" + path.Base(path.Dir(dir)) + path.Base(dir)),
+		}
+		request.FileToGenerate = append(request.FileToGenerate, file.GetName())
+		request.ProtoFile = append(request.ProtoFile, file)
+	}
+	options, run := newPluginOptions()
+	plugin, err := options.New(request)
+	assert.Nil(t, err)
+	if err := run(plugin); err != nil {
+		plugin.Error(err)
+	}
+	return plugin.Response()
+}
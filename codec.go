@@ -91,6 +91,23 @@ type stableCodec interface {
 	IsBinary() bool
 }
 
+// The protobuf binary codec uses the optimized methods generated by
+// vtprotobuf (github.com/planetscale/vtprotobuf) when messages have them.
+// They're detected by method name, so this package doesn't depend on
+// vtprotobuf.
+type vtMarshaler interface {
+	MarshalVT() ([]byte, error)
+}
+
+type vtSizedMarshaler interface {
+	SizeVT() int
+	MarshalToSizedBufferVT([]byte) (int, error)
+}
+
+type vtUnmarshaler interface {
+	UnmarshalVT([]byte) error
+}
+
 type protoBinaryCodec struct{}
 
 var _ Codec = (*protoBinaryCodec)(nil)
@@ -102,6 +119,9 @@ func (c *protoBinaryCodec) Marshal(message any) ([]byte, error) {
 	if !ok {
 		return nil, errNotProto(message)
 	}
+	if vtMessage, ok := message.(vtMarshaler); ok {
+		return vtMessage.MarshalVT()
+	}
 	return proto.Marshal(protoMessage)
 }
 
@@ -110,6 +130,25 @@ func (c *protoBinaryCodec) MarshalAppend(dst []byte, message any) ([]byte, error
 	if !ok {
 		return nil, errNotProto(message)
 	}
+	if vtMessage, ok := message.(vtSizedMarshaler); ok {
+		size := vtMessage.SizeVT()
+		start := len(dst)
+		if cap(dst)-start < size {
+			grown := make([]byte, start, start+size)
+			copy(grown, dst)
+			dst = grown
+		}
+		dst = dst[:start+size]
+		// MarshalToSizedBufferVT fills the buffer from the end.
+		n, err := vtMessage.MarshalToSizedBufferVT(dst[start:])
+		if err != nil {
+			return nil, err
+		}
+		if n != size {
+			return nil, fmt.Errorf("marshal %T: wrote %d bytes, expected %d", message, n, size)
+		}
+		return dst, nil
+	}
 	return proto.MarshalOptions{}.MarshalAppend(dst, protoMessage)
 }
 
@@ -118,7 +157,14 @@ func (c *protoBinaryCodec) Unmarshal(data []byte, message any) error {
 	if !ok {
 		return errNotProto(message)
 	}
-	err := proto.Unmarshal(data, protoMessage)
+	var err error
+	if vtMessage, ok := message.(vtUnmarshaler); ok {
+		// Unlike proto.Unmarshal, UnmarshalVT merges into the existing message.
+		proto.Reset(protoMessage)
+		err = vtMessage.UnmarshalVT(data)
+	} else {
+		err = proto.Unmarshal(data, protoMessage)
+	}
 	if err != nil {
 		return fmt.Errorf("unmarshal into %T: %w", message, err)
 	}
@@ -144,11 +190,22 @@ func (c *protoBinaryCodec) IsBinary() bool {
 }
 
 type protoJSONCodec struct {
-	name string
+	name             string
+	marshalOptions   protojson.MarshalOptions
+	unmarshalOptions protojson.UnmarshalOptions
 }
 
 var _ Codec = (*protoJSONCodec)(nil)
 
+func newProtoJSONCodec(name string) *protoJSONCodec {
+	return &protoJSONCodec{
+		name: name,
+		// Discard unknown fields so clients and servers aren't forced to always use
+		// exactly the same version of the schema.
+		unmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+	}
+}
+
 func (c *protoJSONCodec) Name() string { return c.name }
 
 func (c *protoJSONCodec) Marshal(message any) ([]byte, error) {
@@ -156,7 +213,7 @@ func (c *protoJSONCodec) Marshal(message any) ([]byte, error) {
 	if !ok {
 		return nil, errNotProto(message)
 	}
-	return protojson.MarshalOptions{}.Marshal(protoMessage)
+	return c.marshalOptions.Marshal(protoMessage)
 }
 
 func (c *protoJSONCodec) MarshalAppend(dst []byte, message any) ([]byte, error) {
@@ -164,7 +221,7 @@ func (c *protoJSONCodec) MarshalAppend(dst []byte, message any) ([]byte, error)
 	if !ok {
 		return nil, errNotProto(message)
 	}
-	return protojson.MarshalOptions{}.MarshalAppend(dst, protoMessage)
+	return c.marshalOptions.MarshalAppend(dst, protoMessage)
 }
 
 func (c *protoJSONCodec) Unmarshal(binary []byte, message any) error {
@@ -175,10 +232,7 @@ func (c *protoJSONCodec) Unmarshal(binary []byte, message any) error {
 	if len(binary) == 0 {
 		return errors.New("zero-length payload is not a valid JSON object")
 	}
-	// Discard unknown fields so clients and servers aren't forced to always use
-	// exactly the same version of the schema.
-	options := protojson.UnmarshalOptions{DiscardUnknown: true}
-	err := options.Unmarshal(binary, protoMessage)
+	err := c.unmarshalOptions.Unmarshal(binary, protoMessage)
 	if err != nil {
 		return fmt.Errorf("unmarshal into %T: %w", message, err)
 	}
@@ -22,6 +22,7 @@ import (
 
 	"connectrpc.com/connect/internal/assert"
 	pingv1 "connectrpc.com/connect/internal/gen/connect/ping/v1"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/structpb"
@@ -128,7 +129,7 @@ func TestStableCodec(t *testing.T) {
 func TestJSONCodec(t *testing.T) {
 	t.Parallel()
 
-	codec := &protoJSONCodec{name: codecNameJSON}
+	codec := newProtoJSONCodec(codecNameJSON)
 
 	t.Run("success", func(t *testing.T) {
 		t.Parallel()
@@ -152,4 +153,76 @@ func TestJSONCodec(t *testing.T) {
 			assert.Sprintf(`error message should explain that "" is not a valid JSON object`),
 		)
 	})
+
+	t.Run("custom options", func(t *testing.T) {
+		t.Parallel()
+		codec := &protoJSONCodec{
+			name:           codecNameJSON,
+			marshalOptions: protojson.MarshalOptions{EmitUnpopulated: true},
+		}
+		data, err := codec.Marshal(&pingv1.PingResponse{})
+		assert.Nil(t, err)
+		assert.True(t, bytes.Contains(data, []byte(`"number"`)))
+		err = codec.Unmarshal([]byte(`{"foo": "bar"}`), &emptypb.Empty{})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestProtoBinaryCodecVTProto(t *testing.T) {
+	t.Parallel()
+	codec := &protoBinaryCodec{}
+	want := &vtPingRequest{PingRequest: &pingv1.PingRequest{Text: "foo", Number: 42}}
+
+	data, err := codec.Marshal(want)
+	assert.Nil(t, err)
+	assert.Equal(t, want.marshals, 1)
+
+	prefix := []byte("prefix")
+	appended, err := codec.MarshalAppend(prefix, want)
+	assert.Nil(t, err)
+	assert.Equal(t, want.marshals, 2)
+	assert.Equal(t, appended, append(prefix, data...))
+
+	// Unmarshaling must replace the existing contents, even though
+	// UnmarshalVT merges.
+	got := &vtPingRequest{PingRequest: &pingv1.PingRequest{Text: "stale", Number: 1}}
+	assert.Nil(t, codec.Unmarshal(data, got))
+	assert.Equal(t, got.unmarshals, 1)
+	assert.True(t, proto.Equal(got.PingRequest, want.PingRequest))
+	data, err = proto.Marshal(&pingv1.PingRequest{Number: 7})
+	assert.Nil(t, err)
+	assert.Nil(t, codec.Unmarshal(data, got))
+	assert.Equal(t, got.GetText(), "")
+	assert.Equal(t, got.GetNumber(), 7)
+}
+
+// vtPingRequest mimics the methods generated by vtprotobuf.
+type vtPingRequest struct {
+	*pingv1.PingRequest
+
+	marshals   int
+	unmarshals int
+}
+
+func (m *vtPingRequest) MarshalVT() ([]byte, error) {
+	m.marshals++
+	return proto.Marshal(m.PingRequest)
+}
+
+func (m *vtPingRequest) SizeVT() int {
+	return proto.Size(m.PingRequest)
+}
+
+func (m *vtPingRequest) MarshalToSizedBufferVT(dst []byte) (int, error) {
+	m.marshals++
+	data, err := proto.Marshal(m.PingRequest)
+	if err != nil {
+		return 0, err
+	}
+	return copy(dst[len(dst)-len(data):], data), nil
+}
+
+func (m *vtPingRequest) UnmarshalVT(data []byte) error {
+	m.unmarshals++
+	return proto.UnmarshalOptions{Merge: true}.Unmarshal(data, m.PingRequest)
 }
@@ -15,8 +15,11 @@
 package connect
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"net/http"
+	"strings"
 	"testing"
 
 	"connectrpc.com/connect/internal/assert"
@@ -159,3 +162,45 @@ func TestHandlerCompressionOptionTest(t *testing.T) {
 		checkPools(t, config)
 	})
 }
+
+func TestCompressionLevelOption(t *testing.T) {
+	t.Parallel()
+	const testProc = "/service/method"
+	compressedSize := func(t *testing.T, opts ...HandlerOption) int {
+		t.Helper()
+		config := newHandlerConfig(testProc, StreamTypeUnary, opts)
+		pool := config.CompressionPools[compressionGzip]
+		assert.NotNil(t, pool)
+		src := bytes.NewBufferString(strings.Repeat("connect", 1024))
+		var dst bytes.Buffer
+		assert.Nil(t, pool.Compress(&dst, src))
+		return dst.Len()
+	}
+
+	t.Run("levels", func(t *testing.T) {
+		t.Parallel()
+		huffman := compressedSize(t, WithCompressionLevel(gzip.HuffmanOnly))
+		best := compressedSize(t, WithCompressionLevel(gzip.BestCompression))
+		assert.True(t, best < huffman, assert.Sprintf("best=%d, huffman=%d", best, huffman))
+	})
+	t.Run("invalid-level", func(t *testing.T) {
+		t.Parallel()
+		opts := []HandlerOption{WithCompressionLevel(42)}
+		handlerErr := newHandlerConfig(testProc, StreamTypeUnary, opts).validate()
+		assert.NotNil(t, handlerErr)
+		assert.Equal(t, CodeOf(handlerErr), CodeInternal)
+		_, clientErr := newClientConfig("http://localhost"+testProc, []ClientOption{WithCompressionLevel(gzip.HuffmanOnly - 1)})
+		assert.NotNil(t, clientErr)
+		assert.Equal(t, clientErr.Code(), CodeUnknown)
+	})
+	t.Run("unregistered-noop", func(t *testing.T) {
+		t.Parallel()
+		opts := []HandlerOption{
+			WithCompression(compressionGzip, nil, nil),
+			WithCompressionLevel(gzip.BestSpeed),
+		}
+		config := newHandlerConfig(testProc, StreamTypeUnary, opts)
+		assert.Equal(t, config.CompressionNames, nil)
+		assert.Equal(t, len(config.CompressionPools), 0)
+	})
+}
@@ -42,6 +42,7 @@ type ErrorWriter struct {
 	bufferPool                   *bufferPool
 	protobuf                     Codec
 	requireConnectProtocolHeader bool
+	statusOverrides              map[Code]int
 }
 
 // NewErrorWriter constructs an ErrorWriter. Handler options may be passed to
@@ -57,6 +58,7 @@ func NewErrorWriter(opts ...HandlerOption) *ErrorWriter {
 		bufferPool:                   config.BufferPool,
 		protobuf:                     codecs.Protobuf(),
 		requireConnectProtocolHeader: config.RequireConnectProtocolHeader,
+		statusOverrides:              config.HTTPStatusOverrides,
 	}
 }
 
@@ -130,7 +132,7 @@ func (w *ErrorWriter) writeConnectUnary(response http.ResponseWriter, err error)
 	if connectErr, ok := asError(err); ok && !connectErr.wireErr {
 		mergeNonProtocolHeaders(response.Header(), connectErr.meta)
 	}
-	response.WriteHeader(connectCodeToHTTP(CodeOf(err)))
+	response.WriteHeader(connectHTTPStatus(CodeOf(err), w.statusOverrides))
 	data, marshalErr := json.Marshal(newConnectWireError(err))
 	if marshalErr != nil {
 		return fmt.Errorf("marshal error: %w", marshalErr)
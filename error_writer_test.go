@@ -15,6 +15,7 @@
 package connect
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -50,6 +51,21 @@ func TestErrorWriter(t *testing.T) {
 			assert.True(t, writer.IsSupported(req))
 		})
 	})
+	t.Run("HTTPStatusOverrides", func(t *testing.T) {
+		t.Parallel()
+		writer := NewErrorWriter(WithHTTPStatusOverrides(map[Code]int{
+			CodeUnavailable: http.StatusTooManyRequests,
+			CodeNotFound:    http.StatusOK, // invalid, so ignored
+		}))
+		req := httptest.NewRequest(http.MethodPost, "http://localhost", nil)
+		req.Header.Set("Content-Type", connectUnaryContentTypePrefix+codecNameJSON)
+		recorder := httptest.NewRecorder()
+		assert.Nil(t, writer.Write(recorder, req, NewError(CodeUnavailable, errors.New("busy"))))
+		assert.Equal(t, recorder.Code, http.StatusTooManyRequests)
+		recorder = httptest.NewRecorder()
+		assert.Nil(t, writer.Write(recorder, req, NewError(CodeNotFound, errors.New("missing"))))
+		assert.Equal(t, recorder.Code, http.StatusNotFound)
+	})
 	t.Run("Protocols", func(t *testing.T) {
 		t.Parallel()
 		writer := NewErrorWriter() // All supported by default
@@ -31,6 +31,8 @@ type Handler struct {
 	protocolHandlers map[string][]protocolHandler // Method to protocol handlers
 	allowMethod      string                       // Allow header
 	acceptPost       string                       // Accept-Post header
+	callSlots        chan struct{}                // nil if concurrency is unlimited
+	err              error                        // invalid configuration
 }
 
 // NewUnaryHandler constructs a [Handler] for a request-response procedure.
@@ -82,6 +84,8 @@ func NewUnaryHandler[Req, Res any](
 		protocolHandlers: mappedMethodHandlers(protocolHandlers),
 		allowMethod:      sortedAllowMethodValue(protocolHandlers),
 		acceptPost:       sortedAcceptPostValue(protocolHandlers),
+		callSlots:        config.newCallSlots(),
+		err:              config.validate(),
 	}
 }
 
@@ -234,15 +238,34 @@ func (h *Handler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Re
 		_ = connCloser.Close(timeoutErr)
 		return
 	}
+	if h.err != nil {
+		_ = connCloser.Close(h.err)
+		return
+	}
+	if h.callSlots != nil {
+		select {
+		case h.callSlots <- struct{}{}:
+			defer func() { <-h.callSlots }()
+		default:
+			_ = connCloser.Close(errorf(
+				CodeUnavailable,
+				"too many concurrent calls to %s: limit is %d",
+				h.spec.Procedure, cap(h.callSlots),
+			))
+			return
+		}
+	}
 	_ = connCloser.Close(h.implementation(ctx, connCloser))
 }
 
 type handlerConfig struct {
 	CompressionPools             map[string]*compressionPool
 	CompressionNames             []string
+	CompressionLevel             *int
 	Codecs                       map[string]Codec
 	CompressMinBytes             int
 	Interceptor                  Interceptor
+	Redactor                     *redactHandlerInterceptor
 	Procedure                    string
 	Schema                       any
 	Initializer                  maybeInitializer
@@ -251,6 +274,8 @@ type handlerConfig struct {
 	BufferPool                   *bufferPool
 	ReadMaxBytes                 int
 	SendMaxBytes                 int
+	MaxConcurrentCalls           int
+	HTTPStatusOverrides          map[Code]int
 	StreamType                   StreamType
 }
 
@@ -269,9 +294,31 @@ func newHandlerConfig(procedure string, streamType StreamType, options []Handler
 	for _, opt := range options {
 		opt.applyToHandler(&config)
 	}
+	if config.Redactor != nil {
+		// Redaction must see every error, so it wraps all other interceptors.
+		if config.Interceptor == nil {
+			config.Interceptor = config.Redactor
+		} else {
+			config.Interceptor = newChain([]Interceptor{config.Redactor, config.Interceptor})
+		}
+	}
 	return &config
 }
 
+// validate reports options that can't be applied. Since handler constructors
+// can't return errors, the error is sent in response to every call.
+func (c *handlerConfig) validate() error {
+	if c.CompressionLevel != nil && !isValidGzipLevel(*c.CompressionLevel) {
+		return errorf(CodeInternal, "invalid gzip compression level %d", *c.CompressionLevel)
+	}
+	for code, status := range c.HTTPStatusOverrides {
+		if !isErrorHTTPStatus(status) {
+			return errorf(CodeInternal, "invalid HTTP status %d for %s: must be between 400 and 599", status, code)
+		}
+	}
+	return nil
+}
+
 func (c *handlerConfig) newSpec() Spec {
 	return Spec{
 		Procedure:        c.Procedure,
@@ -281,6 +328,13 @@ func (c *handlerConfig) newSpec() Spec {
 	}
 }
 
+func (c *handlerConfig) newCallSlots() chan struct{} {
+	if c.MaxConcurrentCalls <= 0 {
+		return nil
+	}
+	return make(chan struct{}, c.MaxConcurrentCalls)
+}
+
 func (c *handlerConfig) newProtocolHandlers() []protocolHandler {
 	protocols := []protocol{
 		&protocolConnect{},
@@ -304,6 +358,7 @@ func (c *handlerConfig) newProtocolHandlers() []protocolHandler {
 			SendMaxBytes:                 c.SendMaxBytes,
 			RequireConnectProtocolHeader: c.RequireConnectProtocolHeader,
 			IdempotencyLevel:             c.IdempotencyLevel,
+			HTTPStatusOverrides:          c.HTTPStatusOverrides,
 		}))
 	}
 	return handlers
@@ -323,5 +378,7 @@ func newStreamHandler(
 		protocolHandlers: mappedMethodHandlers(protocolHandlers),
 		allowMethod:      sortedAllowMethodValue(protocolHandlers),
 		acceptPost:       sortedAcceptPostValue(protocolHandlers),
+		callSlots:        config.newCallSlots(),
+		err:              config.validate(),
 	}
 }
@@ -32,6 +32,7 @@ import (
 	pingv1 "connectrpc.com/connect/internal/gen/connect/ping/v1"
 	"connectrpc.com/connect/internal/gen/connect/ping/v1/pingv1connect"
 	"connectrpc.com/connect/internal/memhttp/memhttptest"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/reflect/protoregistry"
 	"google.golang.org/protobuf/types/dynamicpb"
@@ -285,6 +286,220 @@ func TestHandlerMaliciousPrefix(t *testing.T) {
 	wg.Wait()
 }
 
+func TestHandlerProtoJSONOptions(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		pingServer{},
+		connect.WithProtoJSONOptions(
+			protojson.MarshalOptions{EmitUnpopulated: true},
+			protojson.UnmarshalOptions{DiscardUnknown: true},
+		),
+	))
+	server := memhttptest.NewServer(t, mux)
+
+	t.Run("handler", func(t *testing.T) {
+		t.Parallel()
+		request, err := http.NewRequestWithContext(
+			context.Background(),
+			http.MethodPost,
+			server.URL()+pingv1connect.PingServicePingProcedure,
+			strings.NewReader(`{"text": "foo"}`),
+		)
+		assert.Nil(t, err)
+		request.Header.Set("Content-Type", "application/json")
+		response, err := server.Client().Do(request)
+		assert.Nil(t, err)
+		defer response.Body.Close()
+		assert.Equal(t, response.StatusCode, http.StatusOK)
+		body, err := io.ReadAll(response.Body)
+		assert.Nil(t, err)
+		assert.True(t, bytes.Contains(body, []byte(`"number":"0"`)), assert.Sprintf("body: %s", body))
+	})
+	t.Run("client", func(t *testing.T) {
+		t.Parallel()
+		client := pingv1connect.NewPingServiceClient(
+			server.Client(),
+			server.URL(),
+			connect.WithProtoJSONOptions(
+				protojson.MarshalOptions{UseProtoNames: true},
+				protojson.UnmarshalOptions{},
+			),
+		)
+		response, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Number: 42}))
+		assert.Nil(t, err)
+		assert.Equal(t, response.Msg.GetNumber(), 42)
+	})
+}
+
+func TestHandlerMaxConcurrentCalls(t *testing.T) {
+	t.Parallel()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.PingServicePingProcedure, connect.NewUnaryHandler(
+		pingv1connect.PingServicePingProcedure,
+		func(ctx context.Context, request *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+			if request.Msg.GetNumber() == 1 {
+				close(started)
+				<-release
+			}
+			return connect.NewResponse(&pingv1.PingResponse{Number: request.Msg.GetNumber()}), nil
+		},
+		connect.WithMaxConcurrentCalls(1),
+	))
+	server := memhttptest.NewServer(t, mux)
+	client := pingv1connect.NewPingServiceClient(server.Client(), server.URL())
+
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Number: 1}))
+		blocked <- err
+	}()
+	<-started
+	_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Number: 2}))
+	assert.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+	close(release)
+	assert.Nil(t, <-blocked)
+
+	// Once the first call finishes, its slot is available again.
+	response, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{Number: 3}))
+	assert.Nil(t, err)
+	assert.Equal(t, response.Msg.GetNumber(), 3)
+}
+
+func TestHandlerInvalidCompressionLevel(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		&pluggablePingServer{
+			ping: func(_ context.Context, request *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+				return connect.NewResponse(&pingv1.PingResponse{Number: request.Msg.GetNumber()}), nil
+			},
+		},
+		connect.WithCompressionLevel(10),
+	))
+	server := memhttptest.NewServer(t, mux)
+	client := pingv1connect.NewPingServiceClient(server.Client(), server.URL())
+	_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+	assert.Equal(t, connect.CodeOf(err), connect.CodeInternal)
+	assert.True(t, strings.Contains(err.Error(), "invalid gzip compression level 10"))
+}
+
+func TestHandlerHTTPStatusOverrides(t *testing.T) {
+	t.Parallel()
+	overrides := []connect.HandlerOption{
+		connect.WithHTTPStatusOverrides(map[connect.Code]int{
+			connect.CodeUnavailable:   http.StatusTooManyRequests,
+			connect.CodeAlreadyExists: http.StatusUnprocessableEntity,
+		}),
+		connect.WithHTTPStatusOverrides(map[connect.Code]int{
+			connect.CodeAlreadyExists: http.StatusConflict,
+		}),
+	}
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.PingServiceFailProcedure, connect.NewUnaryHandler(
+		pingv1connect.PingServiceFailProcedure,
+		func(_ context.Context, request *connect.Request[pingv1.FailRequest]) (*connect.Response[pingv1.FailResponse], error) {
+			return nil, connect.NewError(connect.Code(request.Msg.GetCode()), errors.New("oops"))
+		},
+		overrides...,
+	))
+	mux.Handle(pingv1connect.PingServiceCountUpProcedure, connect.NewServerStreamHandler(
+		pingv1connect.PingServiceCountUpProcedure,
+		func(context.Context, *connect.Request[pingv1.CountUpRequest], *connect.ServerStream[pingv1.CountUpResponse]) error {
+			return connect.NewError(connect.CodeUnavailable, errors.New("busy"))
+		},
+		overrides...,
+	))
+	server := memhttptest.NewServer(t, mux)
+
+	for code, status := range map[connect.Code]int{
+		connect.CodeUnavailable:   http.StatusTooManyRequests,
+		connect.CodeInternal:      http.StatusInternalServerError,
+		connect.CodeAlreadyExists: http.StatusConflict,
+		connect.CodeNotFound:      http.StatusNotFound,
+	} {
+		request, err := http.NewRequestWithContext(
+			context.Background(),
+			http.MethodPost,
+			server.URL()+pingv1connect.PingServiceFailProcedure,
+			strings.NewReader(fmt.Sprintf(`{"code": %d}`, code)),
+		)
+		assert.Nil(t, err)
+		request.Header.Set("Content-Type", "application/json")
+		response, err := server.Client().Do(request)
+		assert.Nil(t, err)
+		assert.Nil(t, response.Body.Close())
+		assert.Equal(t, response.StatusCode, status, assert.Sprintf("code %v", code))
+	}
+
+	// Clients read the code from the body, so they're unaffected.
+	client := pingv1connect.NewPingServiceClient(server.Client(), server.URL())
+	_, err := client.Fail(context.Background(), connect.NewRequest(&pingv1.FailRequest{
+		Code: int32(connect.CodeUnavailable),
+	}))
+	assert.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+
+	// Streaming Connect, gRPC, and gRPC-Web always respond with 200.
+	for _, contentType := range []string{"application/connect+json", "application/grpc+json", "application/grpc-web+json"} {
+		request, err := http.NewRequestWithContext(
+			context.Background(),
+			http.MethodPost,
+			server.URL()+pingv1connect.PingServiceCountUpProcedure,
+			bytes.NewReader([]byte{0, 0, 0, 0, 2, '{', '}'}), // enveloped JSON message
+		)
+		assert.Nil(t, err)
+		request.Header.Set("Content-Type", contentType)
+		response, err := server.Client().Do(request)
+		assert.Nil(t, err)
+		_, err = io.Copy(io.Discard, response.Body)
+		assert.Nil(t, err)
+		assert.Nil(t, response.Body.Close())
+		assert.Equal(t, response.StatusCode, http.StatusOK, assert.Sprintf("content type %s", contentType))
+	}
+	streamClient := pingv1connect.NewPingServiceClient(server.Client(), server.URL(), connect.WithGRPC())
+	stream, err := streamClient.CountUp(context.Background(), connect.NewRequest(&pingv1.CountUpRequest{}))
+	assert.Nil(t, err)
+	assert.False(t, stream.Receive())
+	assert.Equal(t, connect.CodeOf(stream.Err()), connect.CodeUnavailable)
+	assert.Nil(t, stream.Close())
+}
+
+func TestHandlerInvalidHTTPStatusOverrides(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		&pluggablePingServer{
+			ping: func(_ context.Context, request *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+				return connect.NewResponse(&pingv1.PingResponse{Number: request.Msg.GetNumber()}), nil
+			},
+		},
+		connect.WithHTTPStatusOverrides(map[connect.Code]int{
+			connect.CodeInternal: http.StatusOK,
+		}),
+	))
+	server := memhttptest.NewServer(t, mux)
+	client := pingv1connect.NewPingServiceClient(server.Client(), server.URL())
+	_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+	assert.Equal(t, connect.CodeOf(err), connect.CodeInternal)
+	assert.True(t, strings.Contains(err.Error(), "invalid HTTP status 200 for internal"))
+
+	// The invalid override doesn't apply to the error reporting it.
+	request, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		server.URL()+pingv1connect.PingServicePingProcedure,
+		strings.NewReader("{}"),
+	)
+	assert.Nil(t, err)
+	request.Header.Set("Content-Type", "application/json")
+	response, err := server.Client().Do(request)
+	assert.Nil(t, err)
+	assert.Nil(t, response.Body.Close())
+	assert.Equal(t, response.StatusCode, http.StatusInternalServerError)
+}
+
 func TestDynamicHandler(t *testing.T) {
 	t.Parallel()
 	initializer := func(spec connect.Spec, msg any) error {
@@ -19,6 +19,8 @@ import (
 	"context"
 	"io"
 	"net/http"
+
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 // A ClientOption configures a [Client].
@@ -77,7 +79,7 @@ func WithGRPCWeb() ClientOption {
 // lowerCamelCase, zero values are omitted, missing required fields are errors,
 // enums are emitted as strings, etc.
 func WithProtoJSON() ClientOption {
-	return WithCodec(&protoJSONCodec{codecNameJSON})
+	return WithCodec(newProtoJSONCodec(codecNameJSON))
 }
 
 // WithSendCompression configures the client to use the specified algorithm to
@@ -154,6 +156,26 @@ func WithRecover(handle func(context.Context, Spec, http.Header, any) error) Han
 	return WithInterceptors(&recoverHandlerInterceptor{handle: handle})
 }
 
+// WithInternalErrorRedaction replaces the message of every [CodeInternal] or
+// [CodeUnknown] error returned by a handler with the supplied message, and
+// drops the error's details. Errors that aren't [*Error]s are coded as
+// CodeUnknown, so they're redacted too. This keeps database errors, stack
+// traces, and other implementation details from reaching untrusted clients.
+//
+// The supplied function, which may be nil, receives the original error before
+// it's redacted so that servers can log it. It must be safe to call
+// concurrently.
+//
+// Redaction wraps all other interceptors regardless of where this option
+// appears, so it also applies to errors returned by interceptors and to
+// errors produced from panics by [WithRecover]. If the option is passed more
+// than once, the last one wins.
+func WithInternalErrorRedaction(message string, observe func(context.Context, Spec, error)) HandlerOption {
+	return &internalErrorRedactionOption{
+		Redactor: &redactHandlerInterceptor{message: message, observe: observe},
+	}
+}
+
 // WithRequireConnectProtocolHeader configures the Handler to require requests
 // using the Connect RPC protocol to include the Connect-Protocol-Version
 // header. This ensures that HTTP proxies and net/http middleware can easily
@@ -166,6 +188,37 @@ func WithRequireConnectProtocolHeader() HandlerOption {
 	return &requireConnectProtocolHeaderOption{}
 }
 
+// WithMaxConcurrentCalls limits the number of calls a handler serves
+// concurrently. Once the limit is reached, additional calls fail immediately
+// with [CodeUnavailable] rather than queueing, so clients may safely retry
+// them after a backoff. The limit applies to each [Handler] separately: when
+// passed to a generated service constructor, each procedure gets its own limit.
+//
+// Setting WithMaxConcurrentCalls to zero or a negative number allows any
+// number of concurrent calls, which is the default.
+func WithMaxConcurrentCalls(limit int) HandlerOption {
+	return &maxConcurrentCallsOption{Limit: limit}
+}
+
+// WithHTTPStatusOverrides changes the HTTP status codes used for errors in
+// unary Connect responses. By default, each [Code] maps to the status listed in
+// the Connect protocol specification; statuses in the supplied map take
+// precedence. This is useful when proxies, gateways, or monitoring systems
+// expect particular statuses. Calling this option more than once merges the
+// maps, with later values winning.
+//
+// Only error statuses (400 to 599) are allowed. Handlers configured with any
+// other status respond to every call with [CodeInternal], and ErrorWriters
+// ignore the invalid entries.
+//
+// Connect clients read the error code from the response body, so overrides
+// don't change the errors they see. Streaming Connect responses and the gRPC
+// and gRPC-Web protocols always use HTTP 200 and aren't affected. Pass the same
+// option to [NewErrorWriter] to keep its responses consistent with handlers.
+func WithHTTPStatusOverrides(statuses map[Code]int) HandlerOption {
+	return &httpStatusOverridesOption{Statuses: statuses}
+}
+
 // WithConditionalHandlerOptions allows procedures in the same service to have
 // different configurations: for example, one procedure may need a much larger
 // WithReadMaxBytes setting than the others.
@@ -230,6 +283,26 @@ func WithCodec(codec Codec) Option {
 	return &codecOption{Codec: codec}
 }
 
+// WithProtoJSONOptions replaces the default JSON codec with one that uses the
+// supplied [protojson.MarshalOptions] and [protojson.UnmarshalOptions]. This
+// controls details of the Protobuf JSON mapping, such as whether fields are
+// named using their original proto names, whether enums are emitted as
+// numbers, and whether zero values are included in the output.
+//
+// Handlers use the configured codec for both the "json" and
+// "json; charset=utf-8" names. Clients use it for every request, so applying
+// this option to a client implies [WithProtoJSON].
+//
+// The default codec sets [protojson.UnmarshalOptions.DiscardUnknown], so
+// clients and servers can use different versions of the same schema. Most
+// callers should set it as well.
+func WithProtoJSONOptions(marshal protojson.MarshalOptions, unmarshal protojson.UnmarshalOptions) Option {
+	return &protoJSONOptionsOption{
+		Marshal:   marshal,
+		Unmarshal: unmarshal,
+	}
+}
+
 // WithCompressMinBytes sets a minimum size threshold for compression:
 // regardless of compressor configuration, messages smaller than the configured
 // minimum are sent uncompressed.
@@ -241,6 +314,23 @@ func WithCompressMinBytes(min int) Option {
 	return &compressMinBytesOption{Min: min}
 }
 
+// WithCompressionLevel sets the level used by the built-in gzip compressor,
+// trading CPU for compression ratio. Levels are interpreted as by
+// [gzip.NewWriterLevel]: use constants like [gzip.BestSpeed] and
+// [gzip.BestCompression]. By default, gzip uses [gzip.DefaultCompression].
+//
+// Valid levels range from [gzip.HuffmanOnly] (-2) to [gzip.BestCompression]
+// (9). Clients configured with any other level return an error from every
+// call, and handlers respond to every call with [CodeInternal].
+//
+// The option replaces the implementation registered under the "gzip" name,
+// including any custom gzip implementation registered earlier with
+// [WithCompression] or [WithAcceptCompression]. Setting a level after gzip
+// support has been removed is a no-op.
+func WithCompressionLevel(level int) Option {
+	return &compressionLevelOption{Level: level}
+}
+
 // WithReadMaxBytes limits the performance impact of pathologically large
 // messages sent by the other party. For handlers, WithReadMaxBytes limits the size
 // of a message that the client can send. For clients, WithReadMaxBytes limits the
@@ -419,6 +509,85 @@ func (o *codecOption) applyToHandler(config *handlerConfig) {
 	config.Codecs[o.Codec.Name()] = o.Codec
 }
 
+type protoJSONOptionsOption struct {
+	Marshal   protojson.MarshalOptions
+	Unmarshal protojson.UnmarshalOptions
+}
+
+func (o *protoJSONOptionsOption) applyToClient(config *clientConfig) {
+	config.Codec = o.newCodec(codecNameJSON)
+}
+
+func (o *protoJSONOptionsOption) applyToHandler(config *handlerConfig) {
+	for _, name := range []string{codecNameJSON, codecNameJSONCharsetUTF8} {
+		config.Codecs[name] = o.newCodec(name)
+	}
+}
+
+func (o *protoJSONOptionsOption) newCodec(name string) *protoJSONCodec {
+	return &protoJSONCodec{
+		name:             name,
+		marshalOptions:   o.Marshal,
+		unmarshalOptions: o.Unmarshal,
+	}
+}
+
+type maxConcurrentCallsOption struct {
+	Limit int
+}
+
+func (o *maxConcurrentCallsOption) applyToHandler(config *handlerConfig) {
+	config.MaxConcurrentCalls = o.Limit
+}
+
+type httpStatusOverridesOption struct {
+	Statuses map[Code]int
+}
+
+func (o *httpStatusOverridesOption) applyToHandler(config *handlerConfig) {
+	for code, status := range o.Statuses {
+		if config.HTTPStatusOverrides == nil {
+			config.HTTPStatusOverrides = make(map[Code]int)
+		}
+		config.HTTPStatusOverrides[code] = status
+	}
+}
+
+type internalErrorRedactionOption struct {
+	Redactor *redactHandlerInterceptor
+}
+
+func (o *internalErrorRedactionOption) applyToHandler(config *handlerConfig) {
+	config.Redactor = o.Redactor
+}
+
+type compressionLevelOption struct {
+	Level int
+}
+
+func (o *compressionLevelOption) applyToClient(config *clientConfig) {
+	level := o.Level
+	config.CompressionLevel = &level
+	o.apply(config.CompressionPools)
+}
+
+func (o *compressionLevelOption) applyToHandler(config *handlerConfig) {
+	level := o.Level
+	config.CompressionLevel = &level
+	o.apply(config.CompressionPools)
+}
+
+func (o *compressionLevelOption) apply(configuredPools map[string]*compressionPool) {
+	if !isValidGzipLevel(o.Level) {
+		// Reported when the config is validated.
+		return
+	}
+	if _, ok := configuredPools[compressionGzip]; !ok {
+		return
+	}
+	configuredPools[compressionGzip] = newGzipCompressionPool(o.Level)
+}
+
 type compressionOption struct {
 	Name            string
 	CompressionPool *compressionPool
@@ -613,22 +782,34 @@ func (o *sendCompressionOption) applyToClient(config *clientConfig) {
 
 func withGzip() Option {
 	return &compressionOption{
-		Name: compressionGzip,
-		CompressionPool: newCompressionPool(
-			func() Decompressor { return &gzip.Reader{} },
-			func() Compressor { return gzip.NewWriter(io.Discard) },
-		),
+		Name:            compressionGzip,
+		CompressionPool: newGzipCompressionPool(gzip.DefaultCompression),
 	}
 }
 
+func isValidGzipLevel(level int) bool {
+	return level >= gzip.HuffmanOnly && level <= gzip.BestCompression
+}
+
+func newGzipCompressionPool(level int) *compressionPool {
+	return newCompressionPool(
+		func() Decompressor { return &gzip.Reader{} },
+		func() Compressor {
+			// The level has already been validated, so this can't fail.
+			writer, _ := gzip.NewWriterLevel(io.Discard, level)
+			return writer
+		},
+	)
+}
+
 func withProtoBinaryCodec() Option {
 	return WithCodec(&protoBinaryCodec{})
 }
 
 func withProtoJSONCodecs() HandlerOption {
 	return WithHandlerOptions(
-		WithCodec(&protoJSONCodec{codecNameJSON}),
-		WithCodec(&protoJSONCodec{codecNameJSONCharsetUTF8}),
+		WithCodec(newProtoJSONCodec(codecNameJSON)),
+		WithCodec(newProtoJSONCodec(codecNameJSONCharsetUTF8)),
 	)
 }
 
@@ -83,6 +83,7 @@ type protocolHandlerParams struct {
 	SendMaxBytes                 int
 	RequireConnectProtocolHeader bool
 	IdempotencyLevel             IdempotencyLevel
+	HTTPStatusOverrides          map[Code]int
 }
 
 // Handler is the server side of a protocol. HTTP handlers typically support
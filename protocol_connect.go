@@ -265,6 +265,7 @@ func (h *connectHandler) NewConn(
 				readMaxBytes:    h.ReadMaxBytes,
 			},
 			responseTrailer: make(http.Header),
+			statusOverrides: h.HTTPStatusOverrides,
 		}
 	} else {
 		conn = &connectStreamingHandlerConn{
@@ -688,6 +689,7 @@ type connectUnaryHandlerConn struct {
 	marshaler       connectUnaryMarshaler
 	unmarshaler     connectUnaryUnmarshaler
 	responseTrailer http.Header
+	statusOverrides map[Code]int
 }
 
 func (hc *connectUnaryHandlerConn) Spec() Spec {
@@ -740,7 +742,7 @@ func (hc *connectUnaryHandlerConn) Close(err error) error {
 	}
 	// In unary Connect, errors always use application/json.
 	setHeaderCanonical(hc.responseWriter.Header(), headerContentType, connectUnaryContentTypeJSON)
-	hc.responseWriter.WriteHeader(connectCodeToHTTP(CodeOf(err)))
+	hc.responseWriter.WriteHeader(connectHTTPStatus(CodeOf(err), hc.statusOverrides))
 	data, marshalErr := json.Marshal(newConnectWireError(err))
 	if marshalErr != nil {
 		_ = hc.request.Body.Close()
@@ -1266,6 +1268,19 @@ type connectEndStreamMessage struct {
 	Trailer http.Header       `json:"metadata,omitempty"`
 }
 
+// connectHTTPStatus returns the HTTP status for a unary error, preferring any
+// valid overrides configured with WithHTTPStatusOverrides.
+func connectHTTPStatus(code Code, overrides map[Code]int) int {
+	if status, ok := overrides[code]; ok && isErrorHTTPStatus(status) {
+		return status
+	}
+	return connectCodeToHTTP(code)
+}
+
+func isErrorHTTPStatus(status int) bool {
+	return status >= 400 && status <= 599
+}
+
 func connectCodeToHTTP(code Code) int {
 	// Return literals rather than named constants from the HTTP package to make
 	// it easier to compare this function to the Connect specification.
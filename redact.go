@@ -0,0 +1,70 @@
+// Copyright 2021-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	"context"
+	"errors"
+)
+
+// redactHandlerInterceptor replaces the messages of internal errors returned
+// by handlers, so that details like stack traces and SQL errors aren't sent
+// to untrusted clients.
+type redactHandlerInterceptor struct {
+	Interceptor
+
+	message string
+	observe func(context.Context, Spec, error)
+}
+
+func (i *redactHandlerInterceptor) WrapUnary(next UnaryFunc) UnaryFunc {
+	return func(ctx context.Context, req AnyRequest) (AnyResponse, error) {
+		res, err := next(ctx, req)
+		if err == nil || req.Spec().IsClient {
+			return res, err
+		}
+		return res, i.redact(ctx, req.Spec(), err)
+	}
+}
+
+func (i *redactHandlerInterceptor) WrapStreamingHandler(next StreamingHandlerFunc) StreamingHandlerFunc {
+	return func(ctx context.Context, conn StreamingHandlerConn) error {
+		err := next(ctx, conn)
+		if err == nil {
+			return nil
+		}
+		return i.redact(ctx, conn.Spec(), err)
+	}
+}
+
+func (i *redactHandlerInterceptor) redact(ctx context.Context, spec Spec, err error) error {
+	// Context errors are only coded once the conn is closed, so code them here
+	// to keep cancellations and timeouts from looking like internal errors.
+	err = wrapIfContextError(err)
+	code := CodeOf(err)
+	if code != CodeInternal && code != CodeUnknown {
+		return err
+	}
+	if i.observe != nil {
+		i.observe(ctx, spec, err)
+	}
+	redacted := NewError(code, errors.New(i.message))
+	if connectErr, ok := asError(err); ok {
+		// Metadata is set deliberately by the handler, so it's safe to keep.
+		// Details may describe the failure, so they're dropped.
+		mergeHeaders(redacted.Meta(), connectErr.meta)
+	}
+	return redacted
+}
@@ -0,0 +1,188 @@
+// Copyright 2021-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	connect "connectrpc.com/connect"
+	"connectrpc.com/connect/internal/assert"
+	pingv1 "connectrpc.com/connect/internal/gen/connect/ping/v1"
+	"connectrpc.com/connect/internal/gen/connect/ping/v1/pingv1connect"
+	"connectrpc.com/connect/internal/memhttp/memhttptest"
+)
+
+type leakyPingServer struct {
+	pingv1connect.UnimplementedPingServiceHandler
+}
+
+func (leakyPingServer) Fail(
+	_ context.Context,
+	request *connect.Request[pingv1.FailRequest],
+) (*connect.Response[pingv1.FailResponse], error) {
+	if request.Msg.GetCode() == 0 {
+		return nil, errors.New("pq: password authentication failed for user \"admin\"")
+	}
+	err := connect.NewError(connect.Code(request.Msg.GetCode()), errors.New("secret detail"))
+	err.Meta().Set("X-Request-Id", "42")
+	return nil, err
+}
+
+func (leakyPingServer) Ping(
+	ctx context.Context,
+	_ *connect.Request[pingv1.PingRequest],
+) (*connect.Response[pingv1.PingResponse], error) {
+	ctx, cancel := context.WithTimeout(ctx, 0)
+	defer cancel()
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (leakyPingServer) CountUp(
+	context.Context,
+	*connect.Request[pingv1.CountUpRequest],
+	*connect.ServerStream[pingv1.CountUpResponse],
+) error {
+	panic("secret panic") //nolint:forbidigo
+}
+
+func TestWithInternalErrorRedaction(t *testing.T) {
+	t.Parallel()
+	const redactedMessage = "internal server error"
+	var (
+		mu       sync.Mutex
+		observed []string
+	)
+	observe := func(_ context.Context, spec connect.Spec, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		observed = append(observed, spec.Procedure+": "+err.Error())
+	}
+	handle := func(_ context.Context, _ connect.Spec, _ http.Header, r any) error {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("panic: %v", r))
+	}
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		leakyPingServer{},
+		connect.WithInternalErrorRedaction(redactedMessage, observe),
+		connect.WithRecover(handle),
+	))
+	server := memhttptest.NewServer(t, mux)
+	client := pingv1connect.NewPingServiceClient(server.Client(), server.URL())
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := client.Fail(context.Background(), connect.NewRequest(&pingv1.FailRequest{}))
+		assert.Equal(t, connect.CodeOf(err), connect.CodeUnknown)
+		var connectErr *connect.Error
+		assert.True(t, errors.As(err, &connectErr))
+		assert.Equal(t, connectErr.Message(), redactedMessage)
+	})
+	t.Run("internal", func(t *testing.T) {
+		_, err := client.Fail(context.Background(), connect.NewRequest(&pingv1.FailRequest{
+			Code: int32(connect.CodeInternal),
+		}))
+		var connectErr *connect.Error
+		assert.True(t, errors.As(err, &connectErr))
+		assert.Equal(t, connectErr.Code(), connect.CodeInternal)
+		assert.Equal(t, connectErr.Message(), redactedMessage)
+		assert.Equal(t, connectErr.Meta().Get("X-Request-Id"), "42")
+	})
+	t.Run("not_redacted", func(t *testing.T) {
+		_, err := client.Fail(context.Background(), connect.NewRequest(&pingv1.FailRequest{
+			Code: int32(connect.CodeNotFound),
+		}))
+		var connectErr *connect.Error
+		assert.True(t, errors.As(err, &connectErr))
+		assert.Equal(t, connectErr.Code(), connect.CodeNotFound)
+		assert.Equal(t, connectErr.Message(), "secret detail")
+	})
+	t.Run("context_error", func(t *testing.T) {
+		_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+		var connectErr *connect.Error
+		assert.True(t, errors.As(err, &connectErr))
+		assert.Equal(t, connectErr.Code(), connect.CodeDeadlineExceeded)
+		assert.NotEqual(t, connectErr.Message(), redactedMessage)
+	})
+	t.Run("panic", func(t *testing.T) {
+		stream, err := client.CountUp(context.Background(), connect.NewRequest(&pingv1.CountUpRequest{}))
+		assert.Nil(t, err)
+		defer stream.Close()
+		assert.False(t, stream.Receive())
+		var connectErr *connect.Error
+		assert.True(t, errors.As(stream.Err(), &connectErr))
+		assert.Equal(t, connectErr.Code(), connect.CodeInternal)
+		assert.Equal(t, connectErr.Message(), redactedMessage)
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, observed, []string{
+		pingv1connect.PingServiceFailProcedure + `: pq: password authentication failed for user "admin"`,
+		pingv1connect.PingServiceFailProcedure + ": internal: secret detail",
+		pingv1connect.PingServiceCountUpProcedure + ": internal: panic: secret panic",
+	})
+}
+
+func TestWithInternalErrorRedactionOrder(t *testing.T) {
+	t.Parallel()
+	const redactedMessage = "internal server error"
+	leakyInterceptor := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+			response, err := next(ctx, request)
+			if err != nil {
+				return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("interceptor: %w", err))
+			}
+			return response, nil
+		}
+	})
+	handle := func(_ context.Context, _ connect.Spec, _ http.Header, r any) error {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("panic: %v", r))
+	}
+	mux := http.NewServeMux()
+	// Redaction wraps everything else, even when passed last.
+	mux.Handle(pingv1connect.NewPingServiceHandler(
+		leakyPingServer{},
+		connect.WithRecover(handle),
+		connect.WithInterceptors(leakyInterceptor),
+		connect.WithInternalErrorRedaction(redactedMessage, nil),
+	))
+	server := memhttptest.NewServer(t, mux)
+	client := pingv1connect.NewPingServiceClient(server.Client(), server.URL())
+
+	t.Run("interceptor", func(t *testing.T) {
+		_, err := client.Fail(context.Background(), connect.NewRequest(&pingv1.FailRequest{
+			Code: int32(connect.CodeNotFound),
+		}))
+		var connectErr *connect.Error
+		assert.True(t, errors.As(err, &connectErr))
+		assert.Equal(t, connectErr.Code(), connect.CodeInternal)
+		assert.Equal(t, connectErr.Message(), redactedMessage)
+	})
+	t.Run("panic", func(t *testing.T) {
+		stream, err := client.CountUp(context.Background(), connect.NewRequest(&pingv1.CountUpRequest{}))
+		assert.Nil(t, err)
+		defer stream.Close()
+		assert.False(t, stream.Receive())
+		var connectErr *connect.Error
+		assert.True(t, errors.As(stream.Err(), &connectErr))
+		assert.Equal(t, connectErr.Code(), connect.CodeInternal)
+		assert.Equal(t, connectErr.Message(), redactedMessage)
+	})
+}
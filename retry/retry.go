@@ -0,0 +1,437 @@
+// Copyright 2021-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retry provides a client-side Connect interceptor that automatically
+// retries failed unary calls with exponential backoff.
+//
+// By default, only calls to idempotent procedures (those generated with
+// idempotency_level set to IDEMPOTENT or NO_SIDE_EFFECTS, or configured with
+// [connect.WithIdempotency]) are retried, and only when they fail with
+// [connect.CodeUnavailable]. Streaming calls are never retried, since their
+// messages can't be replayed.
+//
+// When a server suggests how long to wait, either with a google.rpc.RetryInfo
+// error detail or an HTTP Retry-After header, the interceptor waits that long
+// instead of using its own backoff, up to a limit set with
+// [WithMaxServerDelay]. Callers that manage their own retries can read the
+// same hint with [Delay]. Calls are never retried if the caller's deadline
+// would expire before the next attempt.
+//
+// To keep retries from amplifying an outage, [WithBudget] caps them at a
+// fraction of the calls made through the interceptor.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	connect "connectrpc.com/connect"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// PreviousAttemptsHeader is the request header used to tell servers how many
+// times a call has already been attempted. It's omitted from the first
+// attempt. The name matches the header used by gRPC's retry design, so
+// servers can treat retries from Connect and gRPC clients the same way.
+const PreviousAttemptsHeader = "Grpc-Previous-Rpc-Attempts"
+
+const (
+	retryInfoType         = "google.rpc.RetryInfo"
+	retryInfoDelayField   = 1
+	headerRetryAfter      = "Retry-After"
+	defaultMaxAttempts    = 3
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+	defaultMaxServerDelay = 30 * time.Second
+)
+
+// An Option configures an [Interceptor].
+type Option interface {
+	apply(*Interceptor)
+}
+
+// WithMaxAttempts sets the maximum number of attempts for each call, including
+// the first. The default is 3. Values less than 1 are treated as 1, which
+// disables retries.
+func WithMaxAttempts(attempts int) Option {
+	return &maxAttemptsOption{attempts: attempts}
+}
+
+// WithBackoff configures the exponential backoff between attempts. The delay
+// before the first retry is around initial, and it doubles with each
+// subsequent retry up to a ceiling of max. Each delay is randomly jittered
+// into the range [d/2, d] so that clients recovering from the same outage
+// don't retry in lockstep. The defaults are 100ms and 5s. Delays suggested by
+// the server aren't subject to the ceiling; see [WithMaxServerDelay].
+//
+// Non-positive values are ignored.
+func WithBackoff(initial, max time.Duration) Option {
+	return &backoffOption{initial: initial, max: max}
+}
+
+// WithMaxServerDelay sets the longest server-suggested delay the interceptor
+// will wait before retrying. If the server asks for a longer delay, the error
+// is returned to the caller instead. The default is 30s. Non-positive values
+// are ignored.
+func WithMaxServerDelay(max time.Duration) Option {
+	return &maxServerDelayOption{max: max}
+}
+
+// WithCodes sets the error codes that trigger a retry, replacing the default
+// of [connect.CodeUnavailable].
+func WithCodes(codes ...connect.Code) Option {
+	return &codesOption{codes: codes}
+}
+
+// WithNonIdempotentRetries allows retrying calls to procedures that aren't
+// known to be idempotent. Use this only if the server can safely process the
+// same request more than once, since a failed call may still have had side
+// effects.
+func WithNonIdempotentRetries() Option {
+	return &nonIdempotentOption{}
+}
+
+// WithBudget limits retries to a fraction of the calls made through the
+// interceptor, so that a full outage doesn't multiply the load on the failing
+// servers. It uses a token bucket that holds at most burst tokens and starts
+// full: every unary call adds ratio tokens, and every retry spends one. When
+// fewer than one token remains, failed calls are returned without retrying.
+// For example, WithBudget(0.1, 10) allows retries for about 10% of calls,
+// plus an initial burst of 10.
+//
+// The budget is shared by all calls using the same Interceptor. If burst is
+// less than one, the option is ignored. Negative ratios are treated as zero.
+func WithBudget(ratio float64, burst int) Option {
+	return &budgetOption{ratio: ratio, burst: burst}
+}
+
+// Interceptor is a client-side [connect.Interceptor] that retries unary calls.
+// It has no effect on streaming calls or on handlers.
+type Interceptor struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	maxServerDelay time.Duration
+	codes          map[connect.Code]struct{}
+	nonIdempotent  bool
+	budget         *budget // nil if unlimited
+}
+
+var _ connect.Interceptor = (*Interceptor)(nil)
+
+// NewInterceptor constructs a retrying Interceptor. Because it calls the rest
+// of the interceptor chain once per attempt, it's usually best to add it
+// before interceptors that should run on every attempt (such as
+// authentication or logging).
+func NewInterceptor(options ...Option) *Interceptor {
+	interceptor := &Interceptor{
+		maxAttempts:    defaultMaxAttempts,
+		initialBackoff: defaultInitialBackoff,
+		maxBackoff:     defaultMaxBackoff,
+		maxServerDelay: defaultMaxServerDelay,
+		codes:          map[connect.Code]struct{}{connect.CodeUnavailable: {}},
+	}
+	for _, option := range options {
+		option.apply(interceptor)
+	}
+	return interceptor
+}
+
+// WrapUnary implements [connect.Interceptor].
+func (i *Interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+		if !request.Spec().IsClient {
+			return next(ctx, request)
+		}
+		i.budget.deposit()
+		if !i.mayRetry(request.Spec()) {
+			return next(ctx, request)
+		}
+		// The header describes this call only, so don't leave it behind on a
+		// request the caller might reuse.
+		defer request.Header().Del(PreviousAttemptsHeader)
+		var attempt int
+		for {
+			if attempt > 0 {
+				request.Header().Set(PreviousAttemptsHeader, strconv.Itoa(attempt))
+			}
+			response, err := next(ctx, request)
+			attempt++
+			if err == nil || attempt >= i.maxAttempts || !i.isRetryable(err) {
+				return response, err
+			}
+			delay, ok := Delay(err)
+			if ok && delay > i.maxServerDelay {
+				return response, err
+			}
+			if !ok {
+				delay = i.backoff(attempt)
+			}
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+				// Waiting would only run out the caller's deadline.
+				return response, err
+			}
+			if !i.budget.withdraw() {
+				return response, err
+			}
+			if !sleep(ctx, delay) {
+				return nil, err
+			}
+		}
+	}
+}
+
+// WrapStreamingClient implements [connect.Interceptor]. Streams are never
+// retried.
+func (i *Interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler implements [connect.Interceptor]. It has no effect on
+// handlers.
+func (i *Interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+func (i *Interceptor) mayRetry(spec connect.Spec) bool {
+	if i.maxAttempts <= 1 {
+		return false
+	}
+	if i.nonIdempotent {
+		return true
+	}
+	switch spec.IdempotencyLevel {
+	case connect.IdempotencyNoSideEffects, connect.IdempotencyIdempotent:
+		return true
+	default:
+		return false
+	}
+}
+
+func (i *Interceptor) isRetryable(err error) bool {
+	_, ok := i.codes[connect.CodeOf(err)]
+	return ok
+}
+
+// backoff returns the jittered delay before the given retry, counting from 1.
+func (i *Interceptor) backoff(retry int) time.Duration {
+	delay := i.initialBackoff
+	for n := 1; n < retry && delay < i.maxBackoff; n++ {
+		delay *= 2
+	}
+	if delay > i.maxBackoff {
+		delay = i.maxBackoff
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1)) //nolint:gosec // jitter needn't be cryptographically random
+}
+
+// budget is a token bucket shared by all calls through an Interceptor. Its
+// methods are safe to call on a nil budget, which never runs out.
+type budget struct {
+	ratio float64
+	max   float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+func newBudget(ratio float64, burst int) *budget {
+	if ratio < 0 {
+		ratio = 0
+	}
+	return &budget{ratio: ratio, max: float64(burst), tokens: float64(burst)}
+}
+
+func (b *budget) deposit() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += b.ratio
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+}
+
+func (b *budget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Delay returns the server's suggested delay before retrying a failed call, if
+// any. It prefers the google.rpc.RetryInfo error detail, and falls back to the
+// HTTP Retry-After header in the error's metadata, which may be either a
+// number of seconds or an HTTP date.
+func Delay(err error) (time.Duration, bool) {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return 0, false
+	}
+	for _, detail := range connectErr.Details() {
+		if detail.Type() != retryInfoType {
+			continue
+		}
+		if delay, ok := parseRetryInfo(detail.Bytes()); ok {
+			return delay, true
+		}
+	}
+	return parseRetryAfter(connectErr.Meta().Get(headerRetryAfter))
+}
+
+// parseRetryInfo extracts the delay from a serialized google.rpc.RetryInfo
+// without depending on generated code for the googleapis types.
+func parseRetryInfo(data []byte) (time.Duration, bool) {
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return 0, false
+		}
+		data = data[n:]
+		if number != retryInfoDelayField || wireType != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(number, wireType, data)
+			if n < 0 {
+				return 0, false
+			}
+			data = data[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return 0, false
+		}
+		var duration durationpb.Duration
+		if err := proto.Unmarshal(value, &duration); err != nil || duration.CheckValid() != nil {
+			return 0, false
+		}
+		if delay := duration.AsDuration(); delay >= 0 {
+			return delay, true
+		}
+		return 0, false
+	}
+	return 0, false
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := time.Until(date); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
+
+// sleep waits for the given duration, returning false if the context ends
+// first.
+func sleep(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+type maxAttemptsOption struct {
+	attempts int
+}
+
+func (o *maxAttemptsOption) apply(interceptor *Interceptor) {
+	interceptor.maxAttempts = o.attempts
+	if interceptor.maxAttempts < 1 {
+		interceptor.maxAttempts = 1
+	}
+}
+
+type backoffOption struct {
+	initial time.Duration
+	max     time.Duration
+}
+
+func (o *backoffOption) apply(interceptor *Interceptor) {
+	if o.initial > 0 {
+		interceptor.initialBackoff = o.initial
+	}
+	if o.max > 0 {
+		interceptor.maxBackoff = o.max
+	}
+}
+
+type maxServerDelayOption struct {
+	max time.Duration
+}
+
+func (o *maxServerDelayOption) apply(interceptor *Interceptor) {
+	if o.max > 0 {
+		interceptor.maxServerDelay = o.max
+	}
+}
+
+type codesOption struct {
+	codes []connect.Code
+}
+
+func (o *codesOption) apply(interceptor *Interceptor) {
+	interceptor.codes = make(map[connect.Code]struct{}, len(o.codes))
+	for _, code := range o.codes {
+		interceptor.codes[code] = struct{}{}
+	}
+}
+
+type nonIdempotentOption struct{}
+
+func (o *nonIdempotentOption) apply(interceptor *Interceptor) {
+	interceptor.nonIdempotent = true
+}
+
+type budgetOption struct {
+	ratio float64
+	burst int
+}
+
+func (o *budgetOption) apply(interceptor *Interceptor) {
+	if o.burst < 1 {
+		return
+	}
+	interceptor.budget = newBudget(o.ratio, o.burst)
+}
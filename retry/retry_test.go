@@ -0,0 +1,323 @@
+// Copyright 2021-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	connect "connectrpc.com/connect"
+	"connectrpc.com/connect/internal/assert"
+	pingv1 "connectrpc.com/connect/internal/gen/connect/ping/v1"
+	"connectrpc.com/connect/internal/gen/connect/ping/v1/pingv1connect"
+	"connectrpc.com/connect/internal/memhttp/memhttptest"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestInterceptor(t *testing.T) {
+	t.Parallel()
+
+	newInterceptorClient := func(t *testing.T, server *flakyPingServer, interceptor *Interceptor) pingv1connect.PingServiceClient {
+		t.Helper()
+		mux := http.NewServeMux()
+		mux.Handle(pingv1connect.NewPingServiceHandler(server))
+		httpServer := memhttptest.NewServer(t, mux)
+		return pingv1connect.NewPingServiceClient(
+			httpServer.Client(),
+			httpServer.URL(),
+			connect.WithInterceptors(interceptor),
+		)
+	}
+	newClient := func(t *testing.T, server *flakyPingServer, options ...Option) pingv1connect.PingServiceClient {
+		t.Helper()
+		options = append([]Option{WithBackoff(time.Millisecond, time.Millisecond)}, options...)
+		return newInterceptorClient(t, server, NewInterceptor(options...))
+	}
+
+	t.Run("recovers", func(t *testing.T) {
+		t.Parallel()
+		server := &flakyPingServer{failures: 2, code: connect.CodeUnavailable}
+		client := newClient(t, server)
+		request := connect.NewRequest(&pingv1.PingRequest{Number: 42})
+		response, err := client.Ping(context.Background(), request)
+		assert.Nil(t, err)
+		assert.Equal(t, response.Msg.GetNumber(), 42)
+		assert.Equal(t, server.attemptHeaders(), []string{"", "1", "2"})
+		assert.Zero(t, request.Header().Get(PreviousAttemptsHeader))
+		// Reusing the request starts over from the first attempt.
+		_, err = client.Ping(context.Background(), request)
+		assert.Nil(t, err)
+		assert.Equal(t, server.attemptHeaders(), []string{"", "1", "2", ""})
+	})
+	t.Run("exhausted", func(t *testing.T) {
+		t.Parallel()
+		server := &flakyPingServer{failures: 10, code: connect.CodeUnavailable}
+		client := newClient(t, server, WithMaxAttempts(4))
+		_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+		assert.Equal(t, len(server.attemptHeaders()), 4)
+	})
+	t.Run("non_retryable_code", func(t *testing.T) {
+		t.Parallel()
+		server := &flakyPingServer{failures: 10, code: connect.CodeInternal}
+		client := newClient(t, server)
+		_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Equal(t, connect.CodeOf(err), connect.CodeInternal)
+		assert.Equal(t, len(server.attemptHeaders()), 1)
+	})
+	t.Run("custom_codes", func(t *testing.T) {
+		t.Parallel()
+		server := &flakyPingServer{failures: 1, code: connect.CodeResourceExhausted}
+		client := newClient(t, server, WithCodes(connect.CodeResourceExhausted))
+		_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Nil(t, err)
+		assert.Equal(t, len(server.attemptHeaders()), 2)
+	})
+	t.Run("non_idempotent", func(t *testing.T) {
+		t.Parallel()
+		server := &flakyPingServer{}
+		client := newClient(t, server)
+		request := connect.NewRequest(&pingv1.FailRequest{Code: int32(connect.CodeUnavailable)})
+		_, err := client.Fail(context.Background(), request)
+		assert.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+		assert.Equal(t, len(server.attemptHeaders()), 1)
+
+		server = &flakyPingServer{}
+		client = newClient(t, server, WithNonIdempotentRetries())
+		_, err = client.Fail(context.Background(), request)
+		assert.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+		assert.Equal(t, len(server.attemptHeaders()), defaultMaxAttempts)
+	})
+	t.Run("server_delay", func(t *testing.T) {
+		t.Parallel()
+		server := &flakyPingServer{failures: 1, code: connect.CodeUnavailable, retryDelay: time.Millisecond}
+		// The server's hint should override the hour-long backoff.
+		client := newClient(t, server, WithBackoff(time.Hour, time.Hour))
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		_, err := client.Ping(ctx, connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Nil(t, err)
+		assert.Equal(t, len(server.attemptHeaders()), 2)
+	})
+	t.Run("server_delay_too_long", func(t *testing.T) {
+		t.Parallel()
+		server := &flakyPingServer{failures: 1, code: connect.CodeUnavailable, retryDelay: time.Hour}
+		client := newClient(t, server)
+		start := time.Now()
+		_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+		assert.Equal(t, len(server.attemptHeaders()), 1)
+		assert.True(t, time.Since(start) < time.Minute)
+	})
+	t.Run("server_delay_past_deadline", func(t *testing.T) {
+		t.Parallel()
+		server := &flakyPingServer{failures: 1, code: connect.CodeUnavailable, retryDelay: time.Minute}
+		client := newClient(t, server, WithMaxServerDelay(time.Hour))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := client.Ping(ctx, connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+		assert.Equal(t, len(server.attemptHeaders()), 1)
+		// The error is returned right away, not once the deadline expires.
+		assert.Nil(t, ctx.Err())
+	})
+	t.Run("budget", func(t *testing.T) {
+		t.Parallel()
+		server := &flakyPingServer{failures: 100, code: connect.CodeUnavailable}
+		client := newClient(t, server, WithBudget(0.5, 1))
+		var attempts []int
+		for i := 0; i < 3; i++ {
+			before := len(server.attemptHeaders())
+			_, err := client.Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+			assert.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+			attempts = append(attempts, len(server.attemptHeaders())-before)
+		}
+		// The bucket starts with one token, which allows a single retry. After
+		// that, two more calls are needed to earn another.
+		assert.Equal(t, attempts, []int{2, 1, 2})
+	})
+	t.Run("budget_unspent", func(t *testing.T) {
+		t.Parallel()
+		// The only token should survive failures that aren't retried because
+		// of the server's delay or the caller's deadline.
+		interceptor := NewInterceptor(
+			WithBackoff(time.Millisecond, time.Millisecond),
+			WithMaxServerDelay(time.Hour),
+			WithBudget(0, 1),
+		)
+		tooLong := &flakyPingServer{failures: 1, code: connect.CodeUnavailable, retryDelay: 2 * time.Hour}
+		_, err := newInterceptorClient(t, tooLong, interceptor).Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+		assert.Equal(t, len(tooLong.attemptHeaders()), 1)
+		pastDeadline := &flakyPingServer{failures: 1, code: connect.CodeUnavailable, retryDelay: time.Minute}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err = newInterceptorClient(t, pastDeadline, interceptor).Ping(ctx, connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+		assert.Equal(t, len(pastDeadline.attemptHeaders()), 1)
+		server := &flakyPingServer{failures: 1, code: connect.CodeUnavailable}
+		_, err = newInterceptorClient(t, server, interceptor).Ping(context.Background(), connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Nil(t, err)
+		assert.Equal(t, len(server.attemptHeaders()), 2)
+	})
+	t.Run("context_done", func(t *testing.T) {
+		t.Parallel()
+		server := &flakyPingServer{failures: 10, code: connect.CodeUnavailable}
+		client := newClient(t, server, WithBackoff(time.Hour, time.Hour))
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := client.Ping(ctx, connect.NewRequest(&pingv1.PingRequest{}))
+		assert.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+		assert.Equal(t, len(server.attemptHeaders()), 1)
+	})
+}
+
+func TestDelay(t *testing.T) {
+	t.Parallel()
+	newError := func(t *testing.T, retryInfo []byte, retryAfter string) error {
+		t.Helper()
+		err := connect.NewError(connect.CodeUnavailable, errors.New("oops"))
+		if retryInfo != nil {
+			detail, detailErr := connect.NewErrorDetail(&anypb.Any{
+				TypeUrl: "type.googleapis.com/" + retryInfoType,
+				Value:   retryInfo,
+			})
+			assert.Nil(t, detailErr)
+			err.AddDetail(detail)
+		}
+		if retryAfter != "" {
+			err.Meta().Set(headerRetryAfter, retryAfter)
+		}
+		return err
+	}
+
+	delay, ok := Delay(errors.New("not a connect error"))
+	assert.False(t, ok)
+	assert.Equal(t, delay, 0)
+
+	delay, ok = Delay(newError(t, nil, ""))
+	assert.False(t, ok)
+	assert.Equal(t, delay, 0)
+
+	delay, ok = Delay(newError(t, nil, "120"))
+	assert.True(t, ok)
+	assert.Equal(t, delay, 2*time.Minute)
+
+	delay, ok = Delay(newError(t, nil, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)))
+	assert.True(t, ok)
+	assert.True(t, delay > 59*time.Minute && delay <= time.Hour, assert.Sprintf("delay %v", delay))
+
+	_, ok = Delay(newError(t, nil, "soon"))
+	assert.False(t, ok)
+
+	// RetryInfo takes precedence over Retry-After.
+	delay, ok = Delay(newError(t, marshalRetryInfo(t, 1500*time.Millisecond), "120"))
+	assert.True(t, ok)
+	assert.Equal(t, delay, 1500*time.Millisecond)
+
+	// Malformed RetryInfo falls back to Retry-After.
+	delay, ok = Delay(newError(t, []byte{0xff}, "1"))
+	assert.True(t, ok)
+	assert.Equal(t, delay, time.Second)
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	interceptor := NewInterceptor(WithBackoff(100*time.Millisecond, time.Second))
+	for retry, ceiling := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		3:  400 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		50: time.Second,
+	} {
+		delay := interceptor.backoff(retry)
+		assert.True(t, delay >= ceiling/2 && delay <= ceiling, assert.Sprintf("retry %d: delay %v", retry, delay))
+	}
+}
+
+type flakyPingServer struct {
+	pingv1connect.UnimplementedPingServiceHandler
+
+	failures   int
+	code       connect.Code
+	retryDelay time.Duration
+
+	mu      sync.Mutex
+	headers []string
+}
+
+func (s *flakyPingServer) Ping(_ context.Context, request *connect.Request[pingv1.PingRequest]) (*connect.Response[pingv1.PingResponse], error) {
+	if attempt := s.record(request.Header()); attempt <= s.failures {
+		err := connect.NewError(s.code, errors.New("flaky"))
+		if s.retryDelay > 0 {
+			err.Meta().Set(headerRetryAfter, "3600")
+			detail, detailErr := connect.NewErrorDetail(&anypb.Any{
+				TypeUrl: "type.googleapis.com/" + retryInfoType,
+				Value:   marshalRetryInfoValue(s.retryDelay),
+			})
+			if detailErr != nil {
+				return nil, detailErr
+			}
+			err.AddDetail(detail)
+		}
+		return nil, err
+	}
+	return connect.NewResponse(&pingv1.PingResponse{Number: request.Msg.GetNumber()}), nil
+}
+
+func (s *flakyPingServer) Fail(_ context.Context, request *connect.Request[pingv1.FailRequest]) (*connect.Response[pingv1.FailResponse], error) {
+	s.record(request.Header())
+	return nil, connect.NewError(connect.Code(request.Msg.GetCode()), errors.New("fail"))
+}
+
+func (s *flakyPingServer) record(header http.Header) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.headers = append(s.headers, header.Get(PreviousAttemptsHeader))
+	return len(s.headers)
+}
+
+func (s *flakyPingServer) attemptHeaders() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.headers...)
+}
+
+func marshalRetryInfo(t *testing.T, delay time.Duration) []byte {
+	t.Helper()
+	value := marshalRetryInfoValue(delay)
+	assert.NotNil(t, value)
+	return value
+}
+
+// marshalRetryInfoValue serializes a google.rpc.RetryInfo by hand, since this
+// module doesn't depend on the generated googleapis types.
+func marshalRetryInfoValue(delay time.Duration) []byte {
+	duration, err := proto.Marshal(durationpb.New(delay))
+	if err != nil {
+		return nil
+	}
+	value := protowire.AppendTag(nil, retryInfoDelayField, protowire.BytesType)
+	return protowire.AppendBytes(value, duration)
+}
@@ -0,0 +1,85 @@
+// Copyright 2021-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+
+package connect
+
+import (
+	"errors"
+	"io"
+	"iter"
+)
+
+// Messages returns an iterator over the messages in the stream, for use with
+// range-over-func:
+//
+//	for msg, err := range stream.Messages() {
+//		if err != nil {
+//			return err
+//		}
+//		// use msg
+//	}
+//
+// If the stream ends with an unexpected error, the final iteration yields a
+// nil message and the error. Reaching the end of the stream normally isn't an
+// error. Breaking out of the loop early leaves the stream open.
+func (s *ServerStreamForClient[Res]) Messages() iter.Seq2[*Res, error] {
+	return receiveAll(s.Receive, s.Msg, s.Err)
+}
+
+// Messages returns an iterator over the messages in the stream. See
+// [ServerStreamForClient.Messages] for details.
+func (c *ClientStream[Req]) Messages() iter.Seq2[*Req, error] {
+	return receiveAll(c.Receive, c.Msg, c.Err)
+}
+
+// Messages returns an iterator over the messages received from the server.
+// See [ServerStreamForClient.Messages] for details.
+func (b *BidiStreamForClient[Req, Res]) Messages() iter.Seq2[*Res, error] {
+	return receiveEach(b.Receive)
+}
+
+// Messages returns an iterator over the messages received from the client.
+// See [ServerStreamForClient.Messages] for details.
+func (b *BidiStream[Req, Res]) Messages() iter.Seq2[*Req, error] {
+	return receiveEach(b.Receive)
+}
+
+func receiveAll[T any](receive func() bool, msg func() *T, err func() error) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		for receive() {
+			if !yield(msg(), nil) {
+				return
+			}
+		}
+		if err := err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+func receiveEach[T any](receive func() (*T, error)) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		for {
+			msg, err := receive()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(msg, err) || err != nil {
+				return
+			}
+		}
+	}
+}
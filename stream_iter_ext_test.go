@@ -0,0 +1,124 @@
+// Copyright 2021-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+
+package connect_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	connect "connectrpc.com/connect"
+	"connectrpc.com/connect/internal/assert"
+	pingv1 "connectrpc.com/connect/internal/gen/connect/ping/v1"
+	"connectrpc.com/connect/internal/gen/connect/ping/v1/pingv1connect"
+	"connectrpc.com/connect/internal/memhttp/memhttptest"
+)
+
+func TestStreamMessages(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.Handle(pingv1connect.NewPingServiceHandler(iterPingServer{pingServer: pingServer{}}))
+	server := memhttptest.NewServer(t, mux)
+	client := pingv1connect.NewPingServiceClient(server.Client(), server.URL())
+
+	t.Run("server_stream", func(t *testing.T) {
+		t.Parallel()
+		stream, err := client.CountUp(context.Background(), connect.NewRequest(&pingv1.CountUpRequest{Number: 3}))
+		assert.Nil(t, err)
+		defer stream.Close()
+		var got []int64
+		for msg, err := range stream.Messages() {
+			assert.Nil(t, err)
+			got = append(got, msg.GetNumber())
+		}
+		assert.Equal(t, got, []int64{1, 2, 3})
+	})
+	t.Run("server_stream_error", func(t *testing.T) {
+		t.Parallel()
+		stream, err := client.CountUp(context.Background(), connect.NewRequest(&pingv1.CountUpRequest{}))
+		assert.Nil(t, err)
+		defer stream.Close()
+		var errs []error
+		for msg, err := range stream.Messages() {
+			assert.Nil(t, msg)
+			errs = append(errs, err)
+		}
+		assert.Equal(t, len(errs), 1)
+		assert.Equal(t, connect.CodeOf(errs[0]), connect.CodeInvalidArgument)
+	})
+	t.Run("client_stream", func(t *testing.T) {
+		t.Parallel()
+		stream := client.Sum(context.Background())
+		for _, number := range []int64{1, 2, 3} {
+			assert.Nil(t, stream.Send(&pingv1.SumRequest{Number: number}))
+		}
+		response, err := stream.CloseAndReceive()
+		assert.Nil(t, err)
+		assert.Equal(t, response.Msg.GetSum(), 6)
+	})
+	t.Run("bidi_stream", func(t *testing.T) {
+		t.Parallel()
+		stream := client.CumSum(context.Background())
+		for _, number := range []int64{1, 2, 3} {
+			assert.Nil(t, stream.Send(&pingv1.CumSumRequest{Number: number}))
+		}
+		assert.Nil(t, stream.CloseRequest())
+		var got []int64
+		for msg, err := range stream.Messages() {
+			assert.Nil(t, err)
+			got = append(got, msg.GetSum())
+		}
+		assert.Equal(t, got, []int64{1, 3, 6})
+		assert.Nil(t, stream.CloseResponse())
+	})
+}
+
+// iterPingServer receives messages with range-over-func.
+type iterPingServer struct {
+	pingServer
+}
+
+func (iterPingServer) Sum(
+	_ context.Context,
+	stream *connect.ClientStream[pingv1.SumRequest],
+) (*connect.Response[pingv1.SumResponse], error) {
+	var sum int64
+	for msg, err := range stream.Messages() {
+		if err != nil {
+			return nil, err
+		}
+		sum += msg.GetNumber()
+	}
+	return connect.NewResponse(&pingv1.SumResponse{Sum: sum}), nil
+}
+
+func (iterPingServer) CumSum(
+	_ context.Context,
+	stream *connect.BidiStream[pingv1.CumSumRequest, pingv1.CumSumResponse],
+) error {
+	var sum int64
+	for msg, err := range stream.Messages() {
+		if err != nil {
+			return err
+		}
+		sum += msg.GetNumber()
+		if err := stream.Send(&pingv1.CumSumResponse{Sum: sum}); err != nil {
+			return err
+		}
+	}
+	return nil
+}